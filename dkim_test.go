@@ -0,0 +1,187 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizeBodyRelaxedCollapsesLeadingWSP(t *testing.T) {
+	got := canonicalizeBodyRelaxed([]byte("  leading space line\r\nsecond line"))
+	want := " leading space line\r\nsecond line\r\n"
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalizeBodyRelaxedCollapsesInteriorWSP(t *testing.T) {
+	got := canonicalizeBodyRelaxed([]byte("a   b\t\tc  \r\n"))
+	want := "a b c\r\n"
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalizeBodyRelaxedDropsTrailingEmptyLines(t *testing.T) {
+	got := canonicalizeBodyRelaxed([]byte("content\r\n\r\n\r\n"))
+	want := "content\r\n"
+	if string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalizeHeaderRelaxed(t *testing.T) {
+	got := canonicalizeHeaderRelaxed("Subject", "  Hello\r\n   World  ")
+	want := "subject:Hello World"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDKIMSignRSARoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der := x509.MarshalPKCS1PrivateKey(key)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der})
+
+	loaded, err := LoadDKIMPrivateKey(pemBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("hello")
+	m.SetBodyPlainText([]byte("  hi there\r\n"))
+	m.Sign(&DKIMSigner{Domain: "example.com", Selector: "s1", PrivateKey: loaded})
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := parseDKIMTags(t, raw.Header.Get("DKIM-Signature"))
+
+	if tags["v"] != "1" {
+		t.Fatalf("v=%q", tags["v"])
+	}
+	if tags["a"] != "rsa-sha256" {
+		t.Fatalf("a=%q", tags["a"])
+	}
+	if tags["c"] != "relaxed/relaxed" {
+		t.Fatalf("c=%q", tags["c"])
+	}
+	if tags["d"] != "example.com" || tags["s"] != "s1" {
+		t.Fatalf("d/s mismatch: %+v", tags)
+	}
+	if tags["b"] == "" {
+		t.Fatal("missing b= signature tag")
+	}
+}
+
+func TestDKIMSignEd25519(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	loaded, err := LoadDKIMPrivateKey(pemBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := &DKIMSigner{Domain: "example.com", Selector: "s1", PrivateKey: loaded}
+	sig, err := signer.Sign([]HeaderField{
+		{"From", "sender@example.com"},
+		{"Subject", "hello"},
+		{"To", "rcpt@example.org"},
+		{"Date", "Mon, 02 Jan 2006 15:04:05 +0000"},
+		{"Message-ID", "<id@example.com>"},
+	}, []byte("body\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := parseDKIMTags(t, sig)
+	if tags["a"] != "ed25519-sha256" {
+		t.Fatalf("a=%q", tags["a"])
+	}
+}
+
+func TestDKIMSignBodyLengthTag(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := int64(4)
+	signer := &DKIMSigner{Domain: "example.com", Selector: "s1", PrivateKey: key, BodyLength: &l}
+
+	sig, err := signer.Sign([]HeaderField{{"From", "a@example.com"}}, []byte("hello world\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := parseDKIMTags(t, sig)
+	if tags["l"] != "4" {
+		t.Fatalf("l=%q, want 4", tags["l"])
+	}
+
+	wantHash := sha256.Sum256([]byte("hell"))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(wantHash[:]) {
+		t.Fatalf("bh doesn't match the truncated body hash")
+	}
+}
+
+func TestDKIMSignRequiresDomainSelectorKey(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	cases := []*DKIMSigner{
+		{Selector: "s1", PrivateKey: key},
+		{Domain: "example.com", PrivateKey: key},
+		{Domain: "example.com", Selector: "s1"},
+	}
+
+	for _, s := range cases {
+		if _, err := s.Sign(nil, nil); err == nil {
+			t.Errorf("%+v: expected an error", s)
+		}
+	}
+}
+
+func parseDKIMTags(t *testing.T, header string) map[string]string {
+	t.Helper()
+
+	tags := map[string]string{}
+	for _, part := range strings.Split(header, ";") {
+		part = strings.TrimSpace(part)
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 {
+			tags[kv[0]] = kv[1]
+		}
+	}
+	return tags
+}
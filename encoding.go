@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime/quotedprintable"
+)
+
+// Encoding controls how Message text parts are transfer-encoded.
+// EncodingAuto, the zero value, picks quoted-printable. Attachments are
+// always base64-encoded regardless of Encoding, since they're binary.
+type Encoding string
+
+const (
+	EncodingAuto            Encoding = ""
+	EncodingQuotedPrintable Encoding = "quoted-printable"
+	EncodingBase64          Encoding = "base64"
+	EncodingUnencoded       Encoding = "unencoded"
+)
+
+// SetEncoding sets the transfer encoding used for the plain text and HTML
+// body parts.
+func (m *Message) SetEncoding(e Encoding) {
+	m.encoding = e
+}
+
+// encodeTextPart transfer-encodes content per enc, returning the encoded
+// bytes and the Content-Transfer-Encoding value to advertise for them.
+func encodeTextPart(content []byte, enc Encoding) ([]byte, string, error) {
+	switch enc {
+	case EncodingUnencoded:
+		return content, "8bit", nil
+	case EncodingBase64:
+		return encodeBase64(content), "base64", nil
+	default:
+		var buf bytes.Buffer
+		w := quotedprintable.NewWriter(&buf)
+		if _, err := w.Write(content); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "quoted-printable", nil
+	}
+}
+
+// encodeBase64 base64-encodes content and wraps it to 76-column lines with
+// CRLF breaks, as required for body content by RFC 2045.
+func encodeBase64(content []byte) []byte {
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(content)))
+	base64.StdEncoding.Encode(encoded, content)
+	return base64LineBreaker(encoded)
+}
+
+// copyBase64 streams r through a base64 encoder into w, wrapping output to
+// 76-column lines with CRLF breaks, without holding the whole of r in
+// memory.
+func copyBase64(w io.Writer, r io.Reader) error {
+	enc := base64.NewEncoder(base64.StdEncoding, &lineWrapWriter{w: w})
+
+	if _, err := io.Copy(enc, r); err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
+
+// lineWrapWriter inserts a CRLF every 76 bytes written to it. The break is
+// deferred until more data actually follows a full line, so a stream that
+// ends exactly on a 76-byte boundary doesn't get a trailing CRLF that
+// encodeBase64/base64LineBreaker wouldn't produce for the same bytes.
+type lineWrapWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (lw *lineWrapWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		if lw.col == 76 {
+			if _, err := lw.w.Write([]byte("\r\n")); err != nil {
+				return written, err
+			}
+			lw.col = 0
+		}
+
+		n := 76 - lw.col
+		if n > len(p) {
+			n = len(p)
+		}
+
+		if _, err := lw.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+
+		written += n
+		lw.col += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEncodeTextPartQuotedPrintable(t *testing.T) {
+	encoded, cte, err := encodeTextPart([]byte("héllo world"), EncodingAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cte != "quoted-printable" {
+		t.Fatalf("cte = %q, want quoted-printable", cte)
+	}
+
+	decoded, err := readAllQP(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(decoded) != "héllo world" {
+		t.Fatalf("round trip = %q", decoded)
+	}
+}
+
+func TestEncodeTextPartBase64(t *testing.T) {
+	encoded, cte, err := encodeTextPart([]byte("hello world"), EncodingBase64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cte != "base64" {
+		t.Fatalf("cte = %q, want base64", cte)
+	}
+	if strings.Contains(string(encoded), "hello") {
+		t.Fatalf("expected encoded output, got raw content: %q", encoded)
+	}
+}
+
+func TestEncodeTextPartUnencoded(t *testing.T) {
+	encoded, cte, err := encodeTextPart([]byte("plain"), EncodingUnencoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cte != "8bit" {
+		t.Fatalf("cte = %q, want 8bit", cte)
+	}
+	if string(encoded) != "plain" {
+		t.Fatalf("encoded = %q, want unchanged", encoded)
+	}
+}
+
+func TestEncodeHeaderWordASCIIUnchanged(t *testing.T) {
+	if got := encodeHeaderWord("plain subject"); got != "plain subject" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestEncodeHeaderWordNonASCIIPicksShorter(t *testing.T) {
+	got := encodeHeaderWord("héllo")
+	if !strings.HasPrefix(got, "=?UTF-8?") {
+		t.Fatalf("expected an RFC 2047 encoded-word, got %q", got)
+	}
+
+	q := mime.QEncoding.Encode("UTF-8", "héllo")
+	b := mime.BEncoding.Encode("UTF-8", "héllo")
+	want := q
+	if len(b) < len(q) {
+		want = b
+	}
+	if got != want {
+		t.Fatalf("got %q, want the shorter of Q/B encodings (%q)", got, want)
+	}
+}
+
+func TestEncodeAddressDecodesBackToDisplayName(t *testing.T) {
+	addr := &mail.Address{Name: "Jöhn Doe", Address: "john@example.com"}
+	encoded := encodeAddress(addr)
+
+	parsed, err := mail.ParseAddress(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Name != addr.Name || parsed.Address != addr.Address {
+		t.Fatalf("round trip = %+v, want %+v", parsed, addr)
+	}
+}
+
+func readAllQP(b []byte) ([]byte, error) {
+	return io.ReadAll(quotedprintable.NewReader(strings.NewReader(string(b))))
+}
@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"mime"
+	"net/mail"
+	"strings"
+)
+
+// encodeHeaderWord RFC 2047-encodes s if it contains non-ASCII bytes,
+// choosing whichever of Q or B encoding produces the shorter result.
+// ASCII-only input is returned unchanged.
+func encodeHeaderWord(s string) string {
+	if isASCII(s) {
+		return s
+	}
+
+	q := mime.QEncoding.Encode("UTF-8", s)
+	b := mime.BEncoding.Encode("UTF-8", s)
+
+	if len(q) <= len(b) {
+		return q
+	}
+	return b
+}
+
+// encodeAddress formats addr for a header value, RFC 2047-encoding its
+// display name when it isn't ASCII-only.
+func encodeAddress(addr *mail.Address) string {
+	if addr.Name == "" || isASCII(addr.Name) {
+		return addr.String()
+	}
+	return encodeHeaderWord(addr.Name) + " <" + addr.Address + ">"
+}
+
+// encodeAddressList parses each address in addrs and joins their encoded
+// form into a single header value.
+func encodeAddressList(addrs []string) (string, error) {
+	encoded := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		parsed, err := mail.ParseAddress(a)
+		if err != nil {
+			return "", err
+		}
+		encoded = append(encoded, encodeAddress(parsed))
+	}
+	return strings.Join(encoded, ", "), nil
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] > 127 {
+			return false
+		}
+	}
+	return true
+}
@@ -2,10 +2,35 @@ package mail
 
 import (
 	"crypto/rand"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/smtp"
 	"os"
+	"strings"
+)
+
+// Provider selects which Sender implementation Message.Send uses to
+// deliver a message. An empty Provider defaults to ProviderSMTP.
+type Provider string
+
+const (
+	ProviderSMTP     Provider = "smtp"
+	ProviderSendmail Provider = "sendmail"
+	ProviderMailgun  Provider = "mailgun"
+	ProviderSES      Provider = "ses"
+)
+
+// TLSMode selects how SMTPSender and DialSender secure their connection.
+type TLSMode string
+
+const (
+	// TLSNone sends over a plain, unencrypted connection.
+	TLSNone TLSMode = "none"
+	// TLSStartTLS dials plaintext then upgrades with the STARTTLS command.
+	TLSStartTLS TLSMode = "starttls"
+	// TLSImplicit dials straight into TLS, e.g. SMTPS on port 465.
+	TLSImplicit TLSMode = "implicit"
 )
 
 type Options struct {
@@ -13,6 +38,32 @@ type Options struct {
 	Port     string
 	Username string
 	Password string
+
+	// TLS selects the connection security for ProviderSMTP/DialSender.
+	// Defaults to TLSNone.
+	TLS TLSMode
+	// TLSConfig is used as-is for TLSImplicit and STARTTLS handshakes.
+	// ServerName defaults to Host when empty.
+	TLSConfig *tls.Config
+
+	// Provider picks the Sender built by NewSender. Defaults to ProviderSMTP.
+	Provider Provider
+
+	// SendmailPath overrides the sendmail binary for ProviderSendmail.
+	// Defaults to "/usr/sbin/sendmail".
+	SendmailPath string
+
+	// MailgunAPIKey and MailgunDomain configure ProviderMailgun.
+	MailgunAPIKey string
+	MailgunDomain string
+	// MailgunBaseURL overrides the API base URL, defaults to
+	// "https://api.mailgun.net/v3".
+	MailgunBaseURL string
+
+	// SESRegion, SESAccessKeyID and SESSecretAccessKey configure ProviderSES.
+	SESRegion          string
+	SESAccessKeyID     string
+	SESSecretAccessKey string
 }
 
 var ErrEmptyHost = errors.New("host is empty")
@@ -20,9 +71,12 @@ var ErrEmptyPort = errors.New("port is empty")
 var ErrEmptyUsername = errors.New("username is empty")
 var ErrEmptyPassword = errors.New("password is empty")
 
-// Easiest way to get authentication for smtp server
-// See: https://golang.org/pkg/net/smtp/#PlainAuth
-func (m *Options) plainAuth() (smtp.Auth, error) {
+// auth picks an authentication mechanism for client based on what the
+// server advertises in its AUTH extension, preferring CRAM-MD5 over PLAIN
+// over LOGIN so the credentials are protected whenever possible. Some
+// servers (e.g. Office365) only advertise LOGIN over an unencrypted
+// channel, which net/smtp doesn't implement, hence loginAuth.
+func (m *Options) auth(client *smtp.Client) (smtp.Auth, error) {
 	if m.Username == "" {
 		return nil, fmt.Errorf("mail options: %w", ErrEmptyUsername)
 	}
@@ -35,9 +89,35 @@ func (m *Options) plainAuth() (smtp.Auth, error) {
 		return nil, fmt.Errorf("mail options: %w", ErrEmptyHost)
 	}
 
-	auth := smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	_, mechanisms := client.Extension("AUTH")
+
+	switch {
+	case strings.Contains(mechanisms, "CRAM-MD5"):
+		return smtp.CRAMMD5Auth(m.Username, m.Password), nil
+	case strings.Contains(mechanisms, "PLAIN"):
+		return smtp.PlainAuth("", m.Username, m.Password, m.Host), nil
+	case strings.Contains(mechanisms, "LOGIN"):
+		return &loginAuth{username: m.Username, password: m.Password}, nil
+	default:
+		return smtp.PlainAuth("", m.Username, m.Password, m.Host), nil
+	}
+}
+
+// tlsConfig returns m.TLSConfig, cloned and with ServerName defaulted to
+// m.Host, or a fresh config when none was set.
+func (m *Options) tlsConfig() *tls.Config {
+	var cfg *tls.Config
+	if m.TLSConfig != nil {
+		cfg = m.TLSConfig.Clone()
+	} else {
+		cfg = &tls.Config{}
+	}
+
+	if cfg.ServerName == "" {
+		cfg.ServerName = m.Host
+	}
 
-	return auth, nil
+	return cfg
 }
 
 // New is used to create new instance of Message
@@ -0,0 +1,157 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+const defaultMailgunBaseURL = "https://api.mailgun.net/v3"
+
+var ErrEmptyAPIKey = errors.New("api key is empty")
+var ErrEmptyDomain = errors.New("domain is empty")
+
+// MailgunSender delivers a message through the Mailgun HTTP API.
+// See: https://documentation.mailgun.com/en/latest/api-sending.html
+type MailgunSender struct {
+	Options Options
+
+	// Client overrides the http.Client used to call the API, defaults to
+	// http.DefaultClient.
+	Client *http.Client
+}
+
+func (s *MailgunSender) httpClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *MailgunSender) Send(msg *Message) error {
+	if s.Options.MailgunAPIKey == "" {
+		return fmt.Errorf("mail: mailgun: %w", ErrEmptyAPIKey)
+	}
+
+	if s.Options.MailgunDomain == "" {
+		return fmt.Errorf("mail: mailgun: %w", ErrEmptyDomain)
+	}
+
+	baseURL := s.Options.MailgunBaseURL
+	if baseURL == "" {
+		baseURL = defaultMailgunBaseURL
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	if err := writeFormField(form, "from", msg.from); err != nil {
+		return err
+	}
+
+	for _, to := range msg.to {
+		if err := writeFormField(form, "to", to); err != nil {
+			return err
+		}
+	}
+
+	for _, cc := range msg.cc {
+		if err := writeFormField(form, "cc", cc); err != nil {
+			return err
+		}
+	}
+
+	for _, bcc := range msg.bcc {
+		if err := writeFormField(form, "bcc", bcc); err != nil {
+			return err
+		}
+	}
+
+	if err := writeFormField(form, "subject", msg.subject); err != nil {
+		return err
+	}
+
+	if len(msg.plainText) > 0 {
+		if err := writeFormField(form, "text", string(msg.plainText)); err != nil {
+			return err
+		}
+	}
+
+	if len(msg.html) > 0 {
+		if err := writeFormField(form, "html", string(msg.html)); err != nil {
+			return err
+		}
+	}
+
+	for _, attachment := range msg.attachment {
+		if err := writeFormFile(form, "attachment", attachment.Name, attachment.source); err != nil {
+			return err
+		}
+	}
+
+	for _, img := range msg.inline {
+		data := img.Data
+		if err := writeFormFile(form, "inline", img.Name, func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%s/messages", baseURL, s.Options.MailgunDomain)
+	req, err := http.NewRequest(http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetBasicAuth("api", s.Options.MailgunAPIKey)
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mail: mailgun: unexpected status %d: %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
+
+func writeFormField(w *multipart.Writer, field, value string) error {
+	part, err := w.CreateFormField(field)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(part, value)
+	return err
+}
+
+// writeFormFile adds a file form field, reading its content from source
+// (attachment.source or an inline image's already-resident bytes).
+func writeFormFile(w *multipart.Writer, field, filename string, source func() (io.ReadCloser, error)) error {
+	rc, err := source()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(part, rc)
+	return err
+}
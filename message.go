@@ -4,35 +4,58 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"mime/multipart"
+	"io"
+	"mime"
 	"net/mail"
 	"net/textproto"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 )
 
+// Attachment is a file attached to the message. Its content is opened
+// lazily through source when the message is written, so SetAttachment,
+// AttachFile and AttachReader never read the whole file upfront.
 type Attachment struct {
+	Name        string
+	ContentType string
+
+	source func() (io.ReadCloser, error)
+}
+
+// InlineImage is a part embedded in the multipart/related section of the
+// message, referenced from HTML via "cid:<CID>".
+type InlineImage struct {
 	Name        string
 	Data        []byte
 	ContentType string
+	CID         string
+}
+
+// HeaderField is a single RFC 5322 header name/value pair, in the order
+// WriteTo will emit it. A Signer sees these (plus the rendered body) so it
+// can canonicalize and hash exactly what's about to be sent.
+type HeaderField struct {
+	Name  string
+	Value string
 }
 
 type Message struct {
-	Options     MailOptions
+	Options     Options
 	from        string
 	to          []string
 	subject     string
 	cc          []string
 	bcc         []string
-	header      string
 	body        bytes.Buffer
 	plainText   []byte
 	html        []byte
 	boundary    string
 	contentType ContentType
 	attachment  []Attachment
+	inline      []InlineImage
+	encoding    Encoding
+	signer      Signer
 }
 
 type ContentType string
@@ -85,152 +108,87 @@ func (m *Message) SetBodyHTML(content []byte) {
 	m.html = content
 }
 
-// SetAttachment set attachment to email message
-// param name is either path to file or url
+// SetAttachment attaches the file at filename to the message. Opening the
+// file is deferred to WriteTo; it's a thin wrapper around AttachFile.
 func (m *Message) SetAttachment(filename string) error {
+	return m.AttachFile(filename)
+}
+
+// AttachFile attaches the file at filename to the message, deferring
+// opening it until WriteTo runs so large attachments aren't buffered in
+// memory ahead of time.
+func (m *Message) AttachFile(filename string) error {
 	if filename == "" {
 		return fmt.Errorf("message: %w", ErrEmptyAttachment)
 	}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("message: %w", ErrFileNotFound)
+	m.attachment = append(m.attachment, Attachment{
+		Name:        filepath.Base(filename),
+		ContentType: getMimeType(filename),
+		source: func() (io.ReadCloser, error) {
+			file, err := os.Open(filename)
+			if err != nil {
+				return nil, fmt.Errorf("message: %w", ErrFileNotFound)
+			}
+			return file, nil
+		},
+	})
+
+	return nil
+}
+
+// AttachReader attaches r to the message under name, read only when
+// WriteTo runs.
+func (m *Message) AttachReader(name string, r io.Reader, contentType string) error {
+	if name == "" {
+		return fmt.Errorf("message: %w", ErrEmptyAttachment)
 	}
 
-	defer file.Close()
+	m.attachment = append(m.attachment, Attachment{
+		Name:        name,
+		ContentType: contentType,
+		source: func() (io.ReadCloser, error) {
+			return io.NopCloser(r), nil
+		},
+	})
 
-	contentType := filepath.Ext(filename)
+	return nil
+}
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return err
+// EmbedInline reads filename from disk and adds it as an inline part
+// referenced by Content-ID: <cid>, so the HTML body can use
+// <img src="cid:<cid>">.
+func (m *Message) EmbedInline(filename, cid string) error {
+	if filename == "" {
+		return fmt.Errorf("message: %w", ErrEmptyAttachment)
 	}
 
-	fileSize := fileInfo.Size()
-	fileBuffer := make([]byte, fileSize)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("message: %w", ErrFileNotFound)
+	}
 
-	file.Read(fileBuffer)
+	return m.EmbedInlineBytes(filepath.Base(filename), data, cid)
+}
 
-	attachment := Attachment{
-		Name:        fileInfo.Name(),
-		Data:        fileBuffer,
-		ContentType: contentType,
+// EmbedInlineBytes adds data as an inline part referenced by
+// Content-ID: <cid>, so the HTML body can use <img src="cid:<cid>">.
+func (m *Message) EmbedInlineBytes(filename string, data []byte, cid string) error {
+	if filename == "" {
+		return fmt.Errorf("message: %w", ErrEmptyAttachment)
 	}
 
-	m.attachment = append(m.attachment, attachment)
+	m.inline = append(m.inline, InlineImage{
+		Name:        filename,
+		Data:        data,
+		ContentType: getMimeType(filename),
+		CID:         cid,
+	})
 
 	return nil
 }
 
-// Send email to list of recipient with subject and body message
-// func (msg *Message) Send() error {
-// 	if msg.From == "" {
-// 		return fmt.Errorf("message: %w", ErrEmptyFrom)
-// 	}
-
-// 	if len(msg.To) == 0 {
-// 		return fmt.Errorf("message: %w", ErrEmptyTo)
-// 	}
-
-// 	if msg.Subject == "" {
-// 		return fmt.Errorf("message: %w", ErrEmptySubject)
-// 	}
-
-// 	var msgBuilder strings.Builder
-
-// 	msgBuilder.WriteString("From: " + msg.From + "\r\n")
-// 	msgBuilder.WriteString("To: " + strings.Join(msg.To, ",") + "\r\n")
-// 	msgBuilder.WriteString("Subject: " + msg.Subject + "\r\n")
-// 	msgBuilder.WriteString("Message-ID: " + generateMessageID() + "\r\n")
-// 	msgBuilder.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
-// 	msgBuilder.WriteString("MIME-Version: 1.0\r\n")
-
-// 	if len(msg.Cc) > 0 {
-// 		msgBuilder.WriteString("Cc: " + strings.Join(msg.Cc, ",") + "\r\n")
-// 	}
-
-// 	if len(msg.Bcc) > 0 {
-// 		msgBuilder.WriteString("Bcc: " + strings.Join(msg.Bcc, ",") + "\r\n")
-// 	}
-
-// 	if len(msg.body) > 0 {
-// 		msgBuilder.Write(msg.body)
-// 	}
-
-// 	auth, err := msg.Options.plainAuth()
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	err = smtp.SendMail(msg.Options.Host+":"+msg.Options.Port, auth, msg.From, msg.To, []byte(msgBuilder.String()))
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	return nil
-// }
-
-// func (m *Message) SendMultipart() error {
-// 	if m.from == "" {
-// 		return fmt.Errorf("message: %w", ErrEmptyFrom)
-// 	}
-
-// 	if len(m.to) == 0 {
-// 		return fmt.Errorf("message: %w", ErrEmptyTo)
-// 	}
-
-// 	if m.subject == "" {
-// 		return fmt.Errorf("message: %w", ErrEmptySubject)
-// 	}
-
-// 	from, err := mail.ParseAddress(m.from)
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	for _, to := range m.to {
-// 		_, err := mail.ParseAddress(to)
-// 		if err != nil {
-// 			return err
-// 		}
-// 	}
-
-// 	m.header = "From: " + from.String() + "\r\n" +
-// 		"To: " + strings.Join(m.to, ",") + "\r\n" +
-// 		"Subject: " + m.subject + "\r\n" +
-// 		"Message-ID: " + generateMessageID() + "\r\n" +
-// 		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
-// 		"MIME-Version: 1.0\r\n"
-
-// 	if len(m.cc) > 0 {
-// 		m.header += "Cc: " + strings.Join(m.cc, ",") + "\r\n"
-// 	}
-
-// 	if len(m.bcc) > 0 {
-// 		m.header += "Bcc: " + strings.Join(m.bcc, ",") + "\r\n"
-// 	}
-
-// 	if len(m.attachment) > 0 {
-// 		m.header += "Content-Type: multipart/mixed; boundary=\"" + m.boundary + "\""
-// 	}
-
-// 	auth, err := m.Options.plainAuth()
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	log.Println(m.body.String())
-
-// 	body := m.header + m.body.String()
-// 	err = smtp.SendMail(m.Options.Host+":"+m.Options.Port, auth, m.from, m.to, []byte(body))
-// 	if err != nil {
-// 		return err
-// 	}
-
-// 	return nil
-// }
-
-func (m *Message) Send() (err error) {
+func (m *Message) validate() error {
 	if m.from == "" {
 		return fmt.Errorf("message: %w", ErrEmptyFrom)
 	}
@@ -243,113 +201,238 @@ func (m *Message) Send() (err error) {
 		return fmt.Errorf("message: %w", ErrEmptySubject)
 	}
 
+	return nil
+}
+
+// WriteTo builds the full RFC 5322 message (headers + MIME body) and
+// streams it into w, encoding each part as it's written rather than
+// materializing the whole message in memory first.
+func (m *Message) WriteTo(w io.Writer) (int64, error) {
+	if err := m.validate(); err != nil {
+		return 0, err
+	}
+
 	from, err := mail.ParseAddress(m.from)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	for _, to := range m.to {
-		_, err := mail.ParseAddress(to)
-		if err != nil {
-			return err
+		if _, err := mail.ParseAddress(to); err != nil {
+			return 0, err
 		}
 	}
 
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
-
-	var headContentType string
+	var alternative []part
 
 	if len(m.plainText) > 0 {
-		part, err := writer.CreatePart(textproto.MIMEHeader{
-			"Content-Type": {"text/plain; charset=UTF-8"},
-		})
+		encoded, cte, err := encodeTextPart(m.plainText, m.encoding)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
-		_, err = part.Write(m.plainText)
+		alternative = append(alternative, part{
+			header: textproto.MIMEHeader{
+				"Content-Type":              {"text/plain; charset=UTF-8"},
+				"Content-Transfer-Encoding": {cte},
+			},
+			write: writeBytes(encoded),
+		})
+	}
+
+	if len(m.html) > 0 {
+		encoded, cte, err := encodeTextPart(m.html, m.encoding)
 		if err != nil {
-			return err
+			return 0, err
 		}
 
-		headContentType = "text/plain; charset=UTF-8"
+		alternative = append(alternative, part{
+			header: textproto.MIMEHeader{
+				"Content-Type":              {"text/html; charset=UTF-8"},
+				"Content-Transfer-Encoding": {cte},
+			},
+			write: writeBytes(encoded),
+		})
 	}
 
-	if len(m.html) > 0 {
-		part, err := writer.CreatePart(textproto.MIMEHeader{
-			"Content-Type": {"text/html; charset=UTF-8"},
+	text := buildNode("alternative", alternative)
+
+	var related []part
+	if text != nil {
+		related = append(related, *text)
+	}
+
+	for _, img := range m.inline {
+		disposition := mime.FormatMediaType("inline", map[string]string{"filename": img.Name})
+
+		related = append(related, part{
+			header: textproto.MIMEHeader{
+				"Content-Type":              {img.ContentType},
+				"Content-Disposition":       {disposition},
+				"Content-ID":                {"<" + img.CID + ">"},
+				"Content-Transfer-Encoding": {"base64"},
+			},
+			write: writeBytes(encodeBase64(img.Data)),
 		})
+	}
+
+	withInline := buildNode("related", related)
+
+	var mixed []part
+	if withInline != nil {
+		mixed = append(mixed, *withInline)
+	}
+
+	for _, attachment := range m.attachment {
+		attachment := attachment
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name})
+
+		mixed = append(mixed, part{
+			header: textproto.MIMEHeader{
+				"Content-Type":              {attachment.ContentType},
+				"Content-Disposition":       {disposition},
+				"Content-Transfer-Encoding": {"base64"},
+			},
+			write: func(w io.Writer) error {
+				rc, err := attachment.source()
+				if err != nil {
+					return err
+				}
+				defer rc.Close()
+
+				return copyBase64(w, rc)
+			},
+		})
+	}
+
+	root := buildNode("mixed", mixed)
+
+	var headContentType, headTransferEncoding string
+	if root != nil {
+		headContentType = root.header.Get("Content-Type")
+		headTransferEncoding = root.header.Get("Content-Transfer-Encoding")
+	}
+	if headTransferEncoding == "" {
+		headTransferEncoding = "7bit"
+	}
+
+	toHeader, err := encodeAddressList(m.to)
+	if err != nil {
+		return 0, err
+	}
+
+	headers := []HeaderField{
+		{"From", encodeAddress(from)},
+		{"To", toHeader},
+		{"Subject", encodeHeaderWord(m.subject)},
+		{"Message-ID", generateMessageID()},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", headContentType},
+		{"Content-Transfer-Encoding", headTransferEncoding},
+	}
+
+	if len(m.cc) > 0 {
+		ccHeader, err := encodeAddressList(m.cc)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		headers = append(headers, HeaderField{"Cc", ccHeader})
+	}
 
-		_, err = part.Write(m.html)
+	if len(m.bcc) > 0 {
+		bccHeader, err := encodeAddressList(m.bcc)
 		if err != nil {
-			return err
+			return 0, err
 		}
+		headers = append(headers, HeaderField{"Bcc", bccHeader})
+	}
 
-		headContentType = "text/html; charset=UTF-8"
+	cw := &countingWriter{w: w}
 
-		if len(m.plainText) != 0 {
-			headContentType = "multipart/alternative"
+	// Signing needs the whole body to hash, so it's the one case where
+	// WriteTo buffers instead of streaming straight into w.
+	if m.signer != nil {
+		var bodyBuf bytes.Buffer
+		if root != nil {
+			if err := root.write(&bodyBuf); err != nil {
+				return 0, err
+			}
 		}
-	}
 
-	if len(m.attachment) > 0 {
-		for _, attachment := range m.attachment {
-			part, err := writer.CreatePart(textproto.MIMEHeader{
-				"Content-Type":        {attachment.ContentType},
-				"Content-Disposition": {fmt.Sprintf("attachment; filename=\"%s\"", attachment.Name)},
-			})
-			if err != nil {
-				return err
-			}
+		signature, err := m.signer.Sign(headers, bodyBuf.Bytes())
+		if err != nil {
+			return 0, err
+		}
 
-			_, err = part.Write(attachment.Data)
-			if err != nil {
-				return err
-			}
+		if _, err := io.WriteString(cw, "DKIM-Signature: "+signature+"\r\n"); err != nil {
+			return cw.n, err
 		}
 
-		headContentType = "multipart/mixed; boundary=\"" + writer.Boundary() + "\""
-	}
+		if err := writeHeaders(cw, headers); err != nil {
+			return cw.n, err
+		}
 
-	err = writer.Close()
-	if err != nil {
-		return err
+		if _, err := cw.Write(bodyBuf.Bytes()); err != nil {
+			return cw.n, err
+		}
+
+		return cw.n, nil
 	}
 
-	m.header = "From: " + from.String() + "\r\n" +
-		"To: " + strings.Join(m.to, ",") + "\r\n" +
-		"Subject: " + m.subject + "\r\n" +
-		"Message-ID: " + generateMessageID() + "\r\n" +
-		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
-		"MIME-Version: 1.0\r\n" +
-		"Content-Type: " + headContentType + "\r\n" +
-		"Content-Transfer-Encoding: 8bit\r\n"
+	if err := writeHeaders(cw, headers); err != nil {
+		return cw.n, err
+	}
 
-	if len(m.cc) > 0 {
-		m.header += "Cc: " + strings.Join(m.cc, ",") + "\r\n"
+	if root != nil {
+		if err := root.write(cw); err != nil {
+			return cw.n, err
+		}
 	}
 
-	if len(m.bcc) > 0 {
-		m.header += "Bcc: " + strings.Join(m.bcc, ",") + "\r\n"
+	return cw.n, nil
+}
+
+// writeHeaders writes each header field followed by the blank line that
+// separates headers from the body.
+func writeHeaders(w io.Writer, headers []HeaderField) error {
+	var buf bytes.Buffer
+	for _, h := range headers {
+		buf.WriteString(h.Name + ": " + h.Value + "\r\n")
 	}
+	buf.WriteString("\r\n")
 
-	message := m.header + "\r\n" + body.String()
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Sign arranges for s to compute and prepend a DKIM-Signature header the
+// next time the message is written. SMTPSender, DialSender, SendmailSender
+// and SESSender all go through WriteTo and so deliver a signed message;
+// MailgunSender builds its request from the message fields without calling
+// WriteTo, so messages sent through it are not signed.
+func (m *Message) Sign(s Signer) {
+	m.signer = s
+}
 
-	fmt.Println(message)
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	// auth, err := m.Options.plainAuth()
-	// if err != nil {
-	// 	return err
-	// }
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
 
-	// err = smtp.SendMail(m.Options.Host+":"+m.Options.Port, auth, m.from, m.to, []byte(message))
-	// if err != nil {
-	// 	return err
-	// }
+// Send builds the message and hands it to the Sender selected by
+// Options.Provider (see NewSender). Defaults to delivering over SMTP.
+func (m *Message) Send() error {
+	sender, err := NewSender(m.Options)
+	if err != nil {
+		return err
+	}
 
-	return nil
+	return sender.Send(m)
 }
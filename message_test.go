@@ -0,0 +1,93 @@
+package mail
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestAttachFileDefersOpen checks that AttachFile doesn't read the file
+// upfront: it should still succeed for a file that's created only after
+// AttachFile returns, as long as it exists by the time WriteTo runs.
+func TestAttachFileDefersOpen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.txt")
+
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("subject")
+	m.SetBodyPlainText([]byte("body"))
+
+	if err := m.AttachFile(path); err != nil {
+		t.Fatalf("AttachFile on a not-yet-created file should defer opening: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("attachment content"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+}
+
+// TestWriteToReturnsBytesWritten checks the (int64, error) result matches
+// what was actually written to w.
+func TestWriteToReturnsBytesWritten(t *testing.T) {
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("subject")
+	m.SetBodyPlainText([]byte("hello"))
+
+	var buf bytes.Buffer
+	n, err := m.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo reported %d bytes, buffer holds %d", n, buf.Len())
+	}
+}
+
+// TestCopyBase64MatchesEncodeBase64 checks the streaming base64 path
+// produces the same line-wrapped output as the buffered one.
+func TestCopyBase64MatchesEncodeBase64(t *testing.T) {
+	content := bytes.Repeat([]byte("attachment-bytes-"), 20)
+
+	var streamed bytes.Buffer
+	if err := copyBase64(&streamed, bytes.NewReader(content)); err != nil {
+		t.Fatal(err)
+	}
+
+	buffered := encodeBase64(content)
+
+	if streamed.String() != string(buffered) {
+		t.Fatalf("streaming and buffered base64 output differ:\nstreamed: %q\nbuffered: %q", streamed.String(), buffered)
+	}
+}
+
+// TestAttachReaderWrapsNonCloser checks AttachReader accepts a plain
+// io.Reader (no Close method) and still produces a readable attachment.
+func TestAttachReaderWrapsNonCloser(t *testing.T) {
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("subject")
+	m.SetBodyPlainText([]byte("body"))
+
+	if err := m.AttachReader("data.bin", bytes.NewReader([]byte("raw data")), "application/octet-stream"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("expected non-empty output")
+	}
+}
@@ -0,0 +1,72 @@
+package mail
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// part is one node of the message's MIME tree: a header paired with a
+// write func that streams its content into whatever io.Writer the parent
+// gives it. Leaf parts stream pre-encoded bytes; wrapper parts (built by
+// buildNode) stream a nested multipart.Writer.
+type part struct {
+	header textproto.MIMEHeader
+	write  func(w io.Writer) error
+}
+
+func writeBytes(b []byte) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}
+}
+
+// buildNode wraps children in a multipart/subtype container, unless
+// there's only one child, in which case it's returned unchanged. This
+// keeps the message tree from growing a multipart level with nothing to
+// multiplex. Returns nil if there are no children.
+func buildNode(subtype string, children []part) *part {
+	switch len(children) {
+	case 0:
+		return nil
+	case 1:
+		return &children[0]
+	default:
+		boundary := newBoundary()
+
+		return &part{
+			header: textproto.MIMEHeader{
+				"Content-Type": {fmt.Sprintf("multipart/%s; boundary=%q", subtype, boundary)},
+			},
+			write: func(w io.Writer) error {
+				mw := multipart.NewWriter(w)
+				if err := mw.SetBoundary(boundary); err != nil {
+					return err
+				}
+
+				for _, c := range children {
+					pw, err := mw.CreatePart(c.header)
+					if err != nil {
+						return err
+					}
+
+					if err := c.write(pw); err != nil {
+						return err
+					}
+				}
+
+				return mw.Close()
+			},
+		}
+	}
+}
+
+func newBoundary() string {
+	buf := make([]byte, 30)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,179 @@
+package mail
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestBuildNodeCollapsesSingleChild(t *testing.T) {
+	child := part{
+		header: textproto.MIMEHeader{"Content-Type": {"text/plain"}},
+		write:  writeBytes([]byte("hi")),
+	}
+
+	got := buildNode("alternative", []part{child})
+	if got.header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("single child should be returned unchanged, got header %v", got.header)
+	}
+}
+
+func TestBuildNodeWrapsMultipleChildren(t *testing.T) {
+	children := []part{
+		{header: textproto.MIMEHeader{"Content-Type": {"text/plain"}}, write: writeBytes([]byte("plain"))},
+		{header: textproto.MIMEHeader{"Content-Type": {"text/html"}}, write: writeBytes([]byte("<p>html</p>"))},
+	}
+
+	got := buildNode("alternative", children)
+	ct := got.header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "multipart/alternative;") {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	var buf bytes.Buffer
+	if err := got.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	_, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := multipart.NewReader(&buf, params["boundary"])
+	var contentTypes []string
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentTypes = append(contentTypes, p.Header.Get("Content-Type"))
+	}
+
+	if len(contentTypes) != 2 || contentTypes[0] != "text/plain" || contentTypes[1] != "text/html" {
+		t.Fatalf("parts = %v", contentTypes)
+	}
+}
+
+func TestBuildNodeNilForNoChildren(t *testing.T) {
+	if got := buildNode("mixed", nil); got != nil {
+		t.Fatalf("expected nil, got %+v", got)
+	}
+}
+
+// TestWriteToNestsMixedRelatedAlternative builds a message with a plain and
+// HTML body, an inline image and an attachment, and checks the result is a
+// single multipart/mixed tree containing multipart/related containing
+// multipart/alternative, rather than one flat multipart level.
+func TestWriteToNestsMixedRelatedAlternative(t *testing.T) {
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("subject")
+	m.SetBodyPlainText([]byte("plain body"))
+	m.SetBodyHTML([]byte("<p>html body</p>"))
+	if err := m.EmbedInlineBytes("logo.png", []byte("fake-image-bytes"), "logo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AttachReader("notes.txt", strings.NewReader("attachment body"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := mail.ReadMessage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(raw.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("top level = %q, want multipart/mixed", mediaType)
+	}
+
+	mr := multipart.NewReader(raw.Body, params["boundary"])
+
+	var sawRelated, sawAttachment bool
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ct := p.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(ct, "multipart/related"):
+			sawRelated = true
+			if err := checkRelated(t, p); err != nil {
+				t.Fatal(err)
+			}
+		case ct == "text/plain" && p.Header.Get("Content-Disposition") != "":
+			sawAttachment = true
+		}
+	}
+
+	if !sawRelated {
+		t.Fatal("expected a multipart/related part for the body+inline image")
+	}
+	if !sawAttachment {
+		t.Fatal("expected the attachment as a mixed-level part")
+	}
+}
+
+func checkRelated(t *testing.T, p *multipart.Part) error {
+	t.Helper()
+
+	_, params, err := mime.ParseMediaType(p.Header.Get("Content-Type"))
+	if err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(p)
+	if err != nil {
+		return err
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	var sawAlternative, sawInline bool
+	for {
+		inner, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		ct := inner.Header.Get("Content-Type")
+		if strings.HasPrefix(ct, "multipart/alternative") {
+			sawAlternative = true
+		}
+		if inner.Header.Get("Content-Id") != "" {
+			sawInline = true
+		}
+	}
+
+	if !sawAlternative {
+		t.Error("expected multipart/alternative nested inside multipart/related")
+	}
+	if !sawInline {
+		t.Error("expected the inline image nested inside multipart/related")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package parser
+
+import "net/mail"
+
+// ParseAddressList parses a comma-separated RFC 5322 address list header
+// value (the raw value of a To/Cc/Bcc header) into address strings
+// suitable for mail.Message.SetTo/SetCc/SetBcc. RFC 2047 encoded-word
+// display names are decoded by net/mail along the way.
+func ParseAddressList(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	addrs, err := mail.ParseAddressList(value)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		result = append(result, addr.String())
+	}
+
+	return result, nil
+}
+
+// decodeAddress parses a single RFC 5322 address header value (e.g. a
+// From header) and returns it re-formatted by net/mail. An RFC 2047
+// encoded display name is decoded while parsing, but a non-ASCII name comes
+// back re-encoded as an encoded-word in the returned string.
+func decodeAddress(value string) (string, error) {
+	addr, err := mail.ParseAddress(value)
+	if err != nil {
+		return "", err
+	}
+
+	return addr.String(), nil
+}
@@ -0,0 +1,186 @@
+// Package parser parses inbound RFC 5322/2045 messages into *mail.Message
+// values, the inverse of mail.Message.WriteTo.
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	oinmail "github.com/oinpentuls/mail"
+)
+
+var wordDecoder = new(mime.WordDecoder)
+
+// Parse reads an RFC 5322/2045 message from r and returns a populated
+// *mail.Message with its From/To/Cc/Bcc/Subject, plain text and HTML
+// bodies, inline parts and attachments. It transparently handles
+// multipart/mixed, multipart/alternative, multipart/related,
+// quoted-printable, base64 and RFC 2047 encoded-word headers.
+func Parse(r io.Reader) (*oinmail.Message, error) {
+	raw, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := oinmail.New(oinmail.Options{})
+
+	if from := raw.Header.Get("From"); from != "" {
+		decoded, err := decodeAddress(from)
+		if err != nil {
+			return nil, err
+		}
+		msg.SetFrom(decoded)
+	}
+
+	if to, err := ParseAddressList(raw.Header.Get("To")); err != nil {
+		return nil, err
+	} else if len(to) > 0 {
+		msg.SetTo(to)
+	}
+
+	if cc, err := ParseAddressList(raw.Header.Get("Cc")); err != nil {
+		return nil, err
+	} else if len(cc) > 0 {
+		msg.SetCc(cc)
+	}
+
+	if bcc, err := ParseAddressList(raw.Header.Get("Bcc")); err != nil {
+		return nil, err
+	} else if len(bcc) > 0 {
+		msg.SetBcc(bcc)
+	}
+
+	if subject := raw.Header.Get("Subject"); subject != "" {
+		decoded, err := wordDecoder.DecodeHeader(subject)
+		if err != nil {
+			decoded = subject
+		}
+		msg.SetSubject(decoded)
+	}
+
+	state := &assembleState{}
+	err = WalkParts(raw.Body, textproto.MIMEHeader(raw.Header), func(p Part) error {
+		return assemble(msg, state, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// assembleState tracks which body fields assemble has already filled in,
+// since Message only holds one plain text and one HTML body.
+type assembleState struct {
+	plainSet bool
+	htmlSet  bool
+}
+
+// Part is a leaf MIME part discovered while walking a message's tree, with
+// its Content-Transfer-Encoding already decoded.
+type Part struct {
+	Header      textproto.MIMEHeader
+	ContentType string
+	Params      map[string]string
+	Content     []byte
+}
+
+// WalkParts walks r's MIME tree, recursing into any multipart/* nesting,
+// and calls fn once for each leaf part with its content already
+// transfer-decoded. header is the Content-Type/Content-Transfer-Encoding
+// of r itself.
+func WalkParts(r io.Reader, header textproto.MIMEHeader, fn func(Part) error) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(r, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := WalkParts(part, part.Header, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	decoded, err := decodeTransferEncoding(r, header.Get("Content-Transfer-Encoding"))
+	if err != nil {
+		return err
+	}
+
+	content, err := io.ReadAll(decoded)
+	if err != nil {
+		return err
+	}
+
+	return fn(Part{
+		Header:      header,
+		ContentType: mediaType,
+		Params:      params,
+		Content:     content,
+	})
+}
+
+func decodeTransferEncoding(r io.Reader, cte string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(cte)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r), nil
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r), nil
+	default:
+		return r, nil
+	}
+}
+
+// assemble files a parsed leaf part into msg: the first text/plain and
+// text/html parts become the plain/HTML bodies (later ones of the same
+// type are treated as attachments, since the body fields only hold one
+// value each), parts with a Content-ID or an inline disposition become
+// inline images, and everything else becomes an attachment.
+func assemble(msg *oinmail.Message, state *assembleState, p Part) error {
+	_, dispParams, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+	disposition := strings.ToLower(strings.SplitN(p.Header.Get("Content-Disposition"), ";", 2)[0])
+	cid := strings.Trim(p.Header.Get("Content-Id"), "<>")
+
+	switch {
+	case cid != "" || disposition == "inline":
+		name := dispParams["filename"]
+		if name == "" {
+			name = cid
+		}
+		return msg.EmbedInlineBytes(name, p.Content, cid)
+
+	case p.ContentType == "text/plain" && disposition != "attachment" && !state.plainSet:
+		msg.SetBodyPlainText(p.Content)
+		state.plainSet = true
+
+	case p.ContentType == "text/html" && disposition != "attachment" && !state.htmlSet:
+		msg.SetBodyHTML(p.Content)
+		state.htmlSet = true
+
+	default:
+		name := dispParams["filename"]
+		if name == "" {
+			name = "attachment"
+		}
+		return msg.AttachReader(name, bytes.NewReader(p.Content), p.ContentType)
+	}
+
+	return nil
+}
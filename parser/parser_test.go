@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	oinmail "github.com/oinpentuls/mail"
+)
+
+func TestParseRoundTripsPlainAndHTML(t *testing.T) {
+	m := oinmail.New(oinmail.Options{})
+	m.SetFrom("Jöhn Doe <sender@example.com>")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("héllo")
+	m.SetBodyPlainText([]byte("plain body"))
+	m.SetBodyHTML([]byte("<p>html body</p>"))
+	if err := m.AttachReader("notes.txt", bytes.NewReader([]byte("attachment content")), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.EmbedInlineBytes("logo.png", []byte("fake-image-bytes"), "logo"); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := Parse(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var reencoded bytes.Buffer
+	if _, err := parsed.WriteTo(&reencoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(reencoded.Bytes(), []byte("plain body")) {
+		t.Error("missing plain text body after round trip")
+	}
+	if !bytes.Contains(reencoded.Bytes(), []byte("html body")) {
+		t.Error("missing HTML body after round trip")
+	}
+	if !bytes.Contains(reencoded.Bytes(), []byte("notes.txt")) {
+		t.Error("missing attachment after round trip")
+	}
+}
+
+// TestAssembleKeepsFirstPlainTextPart is a regression test: assemble used
+// to call SetBodyPlainText unconditionally on every matching part, so a
+// second, unrelated text/plain part (without an explicit
+// Content-Disposition: attachment) silently overwrote the real body.
+func TestAssembleKeepsFirstPlainTextPart(t *testing.T) {
+	const raw = "From: sender@example.com\r\n" +
+		"To: rcpt@example.org\r\n" +
+		"Subject: subject\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		"the real body\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"some unrelated plain text part\r\n" +
+		"--b1--\r\n"
+
+	parsed, err := Parse(bytes.NewReader([]byte(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := parsed.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(buf.Bytes(), []byte("the real body")) {
+		t.Fatal("expected the first text/plain part to be kept as the body")
+	}
+}
+
+func TestParseAddressList(t *testing.T) {
+	addrs, err := ParseAddressList("a@example.com, \"B C\" <b@example.com>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 {
+		t.Fatalf("got %d addresses, want 2: %v", len(addrs), addrs)
+	}
+}
+
+func TestParseAddressListEmpty(t *testing.T) {
+	addrs, err := ParseAddressList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addrs != nil {
+		t.Fatalf("got %v, want nil", addrs)
+	}
+}
@@ -0,0 +1,196 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/smtp"
+)
+
+// Sender delivers a fully built Message through some transport. NewSender
+// picks an implementation based on Options.Provider, so call sites can
+// swap transports without changing how messages are built or sent.
+type Sender interface {
+	Send(msg *Message) error
+}
+
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// envelopeAddresses parses msg.from/msg.to down to their bare addresses for
+// the SMTP envelope (MAIL FROM/RCPT TO), which RFC 5321 doesn't allow a
+// display name in. The header keeps the original, possibly RFC 2047
+// encoded, form.
+func envelopeAddresses(msg *Message) (from string, to []string, err error) {
+	fromAddr, err := mail.ParseAddress(msg.from)
+	if err != nil {
+		return "", nil, err
+	}
+
+	to = make([]string, 0, len(msg.to))
+	for _, addr := range msg.to {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", nil, err
+		}
+		to = append(to, parsed.Address)
+	}
+
+	return fromAddr.Address, to, nil
+}
+
+// NewSender returns the Sender implementation selected by opt.Provider.
+// An empty Provider defaults to ProviderSMTP.
+func NewSender(opt Options) (Sender, error) {
+	switch opt.Provider {
+	case "", ProviderSMTP:
+		return &SMTPSender{Options: opt}, nil
+	case ProviderSendmail:
+		return &SendmailSender{Options: opt}, nil
+	case ProviderMailgun:
+		return &MailgunSender{Options: opt}, nil
+	case ProviderSES:
+		return &SESSender{Options: opt}, nil
+	default:
+		return nil, fmt.Errorf("mail: %w: %s", ErrUnknownProvider, opt.Provider)
+	}
+}
+
+// SMTPSender delivers a message by dialing Options.Host:Port, securing the
+// connection per Options.TLS, authenticating, then streaming the message
+// over DATA. It reconnects for every Send; DialSender doesn't.
+type SMTPSender struct {
+	Options Options
+}
+
+func (s *SMTPSender) Send(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	from, to, err := envelopeAddresses(msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := dialSMTP(s.Options)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(from); err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// DialSender keeps a single smtp.Client open across multiple Send calls
+// instead of reconnecting per message, which matters for batch sends.
+type DialSender struct {
+	Options Options
+
+	client *smtp.Client
+}
+
+func (s *DialSender) dial() (*smtp.Client, error) {
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	client, err := dialSMTP(s.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	s.client = client
+	return client, nil
+}
+
+func (s *DialSender) Send(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	from, to, err := envelopeAddresses(msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+
+	if err := client.Mail(from); err != nil {
+		s.invalidate()
+		return err
+	}
+
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			s.invalidate()
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		s.invalidate()
+		return err
+	}
+
+	if _, err := msg.WriteTo(w); err != nil {
+		w.Close()
+		s.invalidate()
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		s.invalidate()
+		return err
+	}
+
+	return nil
+}
+
+// invalidate drops the cached connection after a failed transaction: the
+// server's SMTP state afterward is unknown, so the next Send reconnects
+// rather than reusing a connection that might be stuck mid-transaction.
+func (s *DialSender) invalidate() {
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+}
+
+// Close terminates the underlying SMTP connection, if one was opened.
+func (s *DialSender) Close() error {
+	if s.client == nil {
+		return nil
+	}
+
+	err := s.client.Quit()
+	s.client = nil
+	return err
+}
@@ -0,0 +1,208 @@
+package mail
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSenderPicksProvider(t *testing.T) {
+	cases := []struct {
+		provider Provider
+		want     interface{}
+	}{
+		{"", &SMTPSender{}},
+		{ProviderSMTP, &SMTPSender{}},
+		{ProviderSendmail, &SendmailSender{}},
+		{ProviderMailgun, &MailgunSender{}},
+		{ProviderSES, &SESSender{}},
+	}
+
+	for _, c := range cases {
+		sender, err := NewSender(Options{Provider: c.provider})
+		if err != nil {
+			t.Fatalf("provider %q: %v", c.provider, err)
+		}
+
+		switch c.want.(type) {
+		case *SMTPSender:
+			if _, ok := sender.(*SMTPSender); !ok {
+				t.Errorf("provider %q: got %T, want *SMTPSender", c.provider, sender)
+			}
+		case *SendmailSender:
+			if _, ok := sender.(*SendmailSender); !ok {
+				t.Errorf("provider %q: got %T, want *SendmailSender", c.provider, sender)
+			}
+		case *MailgunSender:
+			if _, ok := sender.(*MailgunSender); !ok {
+				t.Errorf("provider %q: got %T, want *MailgunSender", c.provider, sender)
+			}
+		case *SESSender:
+			if _, ok := sender.(*SESSender); !ok {
+				t.Errorf("provider %q: got %T, want *SESSender", c.provider, sender)
+			}
+		}
+	}
+}
+
+func TestNewSenderUnknownProvider(t *testing.T) {
+	_, err := NewSender(Options{Provider: "carrier-pigeon"})
+	if err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+}
+
+// TestSendmailSenderFailsFastOnMissingBinary is a regression test: Send
+// used to spawn a goroutine that wrote the message into an unbuffered pipe
+// before the process had started reading it, so a failing Start (e.g. a
+// nonexistent binary) left that goroutine blocked forever and Send never
+// returned. It must now fail quickly with the exec error instead.
+func TestSendmailSenderFailsFastOnMissingBinary(t *testing.T) {
+	m := New(Options{})
+	m.SetFrom("sender@example.com")
+	m.SetTo([]string{"rcpt@example.org"})
+	m.SetSubject("subject")
+	// A body long enough that, unbuffered, a single Write to the pipe
+	// would block if nothing is reading the other end.
+	m.SetBodyPlainText(make([]byte, 1<<20))
+
+	sender := &SendmailSender{Options: Options{SendmailPath: "/nonexistent/sendmail-binary"}}
+
+	done := make(chan error, 1)
+	go func() { done <- sender.Send(m) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a nonexistent sendmail binary")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send did not return: sendmail goroutine deadlocked")
+	}
+}
+
+// newTransactionMessage builds a Message whose From/To carry a display
+// name, so tests can assert that only the bare address reaches the SMTP
+// envelope.
+func newTransactionMessage() *Message {
+	m := New(Options{})
+	m.SetFrom("Jane Doe <jane@example.com>")
+	m.SetTo([]string{"John Roe <john@example.org>"})
+	m.SetSubject("subject")
+	m.SetBodyPlainText([]byte("body"))
+	return m
+}
+
+func dialOptionsForAddr(t *testing.T, addr string) Options {
+	t.Helper()
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Options{Host: host, Port: port}
+}
+
+// TestSMTPSenderSendsBareEnvelopeAddresses drives SMTPSender.Send through
+// an actual MAIL/RCPT/DATA/QUIT transaction against a fake server: a
+// regression test for the display-name-in-envelope bug, which a test that
+// only exercised envelopeAddresses in isolation wouldn't have caught.
+func TestSMTPSenderSendsBareEnvelopeAddresses(t *testing.T) {
+	addr, result := fakeSMTPServer(t, "")
+
+	sender := &SMTPSender{Options: dialOptionsForAddr(t, addr)}
+	if err := sender.Send(newTransactionMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if result.count() != 1 {
+		t.Fatalf("server recorded %d transactions, want 1", result.count())
+	}
+
+	tx := result.last()
+	if tx.mailFrom != "<jane@example.com>" {
+		t.Errorf("MAIL FROM = %q, want <jane@example.com>", tx.mailFrom)
+	}
+	if len(tx.rcptTo) != 1 || tx.rcptTo[0] != "<john@example.org>" {
+		t.Errorf("RCPT TO = %v, want [<john@example.org>]", tx.rcptTo)
+	}
+	if !strings.Contains(string(tx.data), "body") {
+		t.Errorf("DATA body = %q, want it to contain %q", tx.data, "body")
+	}
+}
+
+// TestDialSenderSendsBareEnvelopeAddresses is the same regression test as
+// above, but for DialSender, which builds the envelope through the same
+// envelopeAddresses helper but a different Send method.
+func TestDialSenderSendsBareEnvelopeAddresses(t *testing.T) {
+	addr, result := fakeSMTPServer(t, "")
+
+	sender := &DialSender{Options: dialOptionsForAddr(t, addr)}
+	defer sender.Close()
+
+	if err := sender.Send(newTransactionMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	tx := result.last()
+	if tx.mailFrom != "<jane@example.com>" {
+		t.Errorf("MAIL FROM = %q, want <jane@example.com>", tx.mailFrom)
+	}
+	if len(tx.rcptTo) != 1 || tx.rcptTo[0] != "<john@example.org>" {
+		t.Errorf("RCPT TO = %v, want [<john@example.org>]", tx.rcptTo)
+	}
+}
+
+// TestDialSenderReusesConnection asserts DialSender's whole point: two
+// sends over one still-open connection only ever dial once.
+func TestDialSenderReusesConnection(t *testing.T) {
+	addr, result := fakeSMTPServer(t, "")
+
+	sender := &DialSender{Options: dialOptionsForAddr(t, addr)}
+	defer sender.Close()
+
+	if err := sender.Send(newTransactionMessage()); err != nil {
+		t.Fatalf("first Send: %v", err)
+	}
+	if err := sender.Send(newTransactionMessage()); err != nil {
+		t.Fatalf("second Send: %v", err)
+	}
+
+	if got := result.connectionCount(); got != 1 {
+		t.Fatalf("server saw %d connections, want 1 (connection should be reused)", got)
+	}
+	if got := result.count(); got != 2 {
+		t.Fatalf("server recorded %d transactions, want 2", got)
+	}
+}
+
+// TestDialSenderInvalidatesConnectionAfterFailedTransaction is a
+// regression test: a failed RCPT used to leave the broken connection
+// cached, so every subsequent Send kept failing against it. Send must now
+// drop the cached client on failure so the next Send reconnects.
+func TestDialSenderInvalidatesConnectionAfterFailedTransaction(t *testing.T) {
+	addr, result := fakeSMTPServer(t, "")
+
+	sender := &DialSender{Options: dialOptionsForAddr(t, addr)}
+	defer sender.Close()
+
+	failing := New(Options{})
+	failing.SetFrom("jane@example.com")
+	failing.SetTo([]string{"reject@example.org"})
+	failing.SetSubject("subject")
+	failing.SetBodyPlainText([]byte("body"))
+
+	if err := sender.Send(failing); err == nil {
+		t.Fatal("expected the rejected RCPT to fail the send")
+	}
+
+	if err := sender.Send(newTransactionMessage()); err != nil {
+		t.Fatalf("Send after a failed transaction should reconnect and succeed: %v", err)
+	}
+
+	if got := result.connectionCount(); got != 2 {
+		t.Fatalf("server saw %d connections, want 2 (a failed transaction should force a reconnect)", got)
+	}
+}
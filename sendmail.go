@@ -0,0 +1,65 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+)
+
+const defaultSendmailPath = "/usr/sbin/sendmail"
+
+// SendmailSender delivers a message by piping it to a local sendmail-like
+// binary invoked with "-t", which reads recipients from the message
+// headers instead of the command line.
+type SendmailSender struct {
+	Options Options
+}
+
+func (s *SendmailSender) Send(msg *Message) error {
+	if err := msg.validate(); err != nil {
+		return err
+	}
+
+	path := s.Options.SendmailPath
+	if path == "" {
+		path = defaultSendmailPath
+	}
+
+	pr, pw := io.Pipe()
+
+	cmd := exec.Command(path, "-t")
+	cmd.Stdin = pr
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// Start before writing: if the binary doesn't exist or fails to
+	// start, nothing has read pr yet, and WriteTo's first Write to the
+	// unbuffered pipe would otherwise block forever.
+	if err := cmd.Start(); err != nil {
+		pr.Close()
+		pw.Close()
+		return fmt.Errorf("mail: sendmail: %w", err)
+	}
+
+	writeErrCh := make(chan error, 1)
+	go func() {
+		_, err := msg.WriteTo(pw)
+		pw.CloseWithError(err)
+		writeErrCh <- err
+	}()
+
+	runErr := cmd.Wait()
+	writeErr := <-writeErrCh
+
+	if runErr != nil {
+		return fmt.Errorf("mail: sendmail: %w: %s", runErr, stderr.String())
+	}
+
+	if writeErr != nil {
+		return fmt.Errorf("mail: sendmail: %w", writeErr)
+	}
+
+	return nil
+}
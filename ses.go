@@ -0,0 +1,112 @@
+package mail
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var ErrEmptyAccessKeyID = errors.New("access key id is empty")
+var ErrEmptySecretAccessKey = errors.New("secret access key is empty")
+var ErrEmptyRegion = errors.New("region is empty")
+
+// SESSender delivers a message through the Amazon SES v2 SendEmail API,
+// signed with AWS Signature Version 4.
+// See: https://docs.aws.amazon.com/ses/latest/APIReference-V2/API_SendEmail.html
+type SESSender struct {
+	Options Options
+
+	// Client overrides the http.Client used to call the API, defaults to
+	// http.DefaultClient.
+	Client *http.Client
+
+	// Now overrides the signing clock, defaults to time.Now.
+	Now func() time.Time
+}
+
+type sesSendEmailRequest struct {
+	Content struct {
+		Raw struct {
+			Data []byte `json:"Data"`
+		} `json:"Raw"`
+	} `json:"Content"`
+	Destination struct {
+		ToAddresses  []string `json:"ToAddresses,omitempty"`
+		CcAddresses  []string `json:"CcAddresses,omitempty"`
+		BccAddresses []string `json:"BccAddresses,omitempty"`
+	} `json:"Destination"`
+	FromEmailAddress string `json:"FromEmailAddress"`
+}
+
+func (s *SESSender) httpClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+func (s *SESSender) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *SESSender) Send(msg *Message) error {
+	if s.Options.SESAccessKeyID == "" {
+		return fmt.Errorf("mail: ses: %w", ErrEmptyAccessKeyID)
+	}
+
+	if s.Options.SESSecretAccessKey == "" {
+		return fmt.Errorf("mail: ses: %w", ErrEmptySecretAccessKey)
+	}
+
+	if s.Options.SESRegion == "" {
+		return fmt.Errorf("mail: ses: %w", ErrEmptyRegion)
+	}
+
+	var buf bytes.Buffer
+	if _, err := msg.WriteTo(&buf); err != nil {
+		return err
+	}
+
+	var payload sesSendEmailRequest
+	payload.Content.Raw.Data = buf.Bytes()
+	payload.FromEmailAddress = msg.from
+	payload.Destination.ToAddresses = msg.to
+	payload.Destination.CcAddresses = msg.cc
+	payload.Destination.BccAddresses = msg.bcc
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("https://email.%s.amazonaws.com/v2/email/outbound-emails", s.Options.SESRegion)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Host = req.URL.Host
+	req.Header.Set("Content-Type", "application/json")
+
+	signAWSRequest(req, body, s.Options.SESAccessKeyID, s.Options.SESSecretAccessKey, s.Options.SESRegion, "ses", s.now())
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("mail: ses: unexpected status %d: %s", resp.StatusCode, respBody)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package mail
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// loginAuth implements the non-standard "LOGIN" SMTP AUTH mechanism used by
+// servers (e.g. Office 365) that don't advertise PLAIN over an unencrypted
+// channel. net/smtp only ships PlainAuth and CRAMMD5Auth.
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSuffix(string(fromServer), ":")) {
+	case "username":
+		return []byte(a.username), nil
+	case "password":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("mail: unexpected LOGIN server prompt: %q", fromServer)
+	}
+}
+
+// dialSMTP connects to opt.Host:opt.Port per opt.TLS, then authenticates
+// if credentials are set. Used by both SMTPSender and DialSender so they
+// share one connection-setup path.
+func dialSMTP(opt Options) (*smtp.Client, error) {
+	addr := opt.Host + ":" + opt.Port
+
+	var client *smtp.Client
+
+	switch opt.TLS {
+	case TLSImplicit:
+		conn, err := tls.Dial("tcp", addr, opt.tlsConfig())
+		if err != nil {
+			return nil, err
+		}
+
+		client, err = smtp.NewClient(conn, opt.Host)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	default:
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err = smtp.NewClient(conn, opt.Host)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		if opt.TLS == TLSStartTLS {
+			if err := client.StartTLS(opt.tlsConfig()); err != nil {
+				client.Close()
+				return nil, err
+			}
+		}
+	}
+
+	if opt.Username != "" || opt.Password != "" {
+		auth, err := opt.auth(client)
+		if err != nil {
+			client.Close()
+			return nil, err
+		}
+
+		if err := client.Auth(auth); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
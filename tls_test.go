@@ -0,0 +1,436 @@
+package mail
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginAuthSequence(t *testing.T) {
+	a := &loginAuth{username: "user", password: "pass"}
+
+	proto, _, err := a.Start(&smtp.ServerInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto != "LOGIN" {
+		t.Fatalf("proto = %q, want LOGIN", proto)
+	}
+
+	reply, err := a.Next([]byte("Username:"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "user" {
+		t.Fatalf("got %q, want username", reply)
+	}
+
+	reply, err = a.Next([]byte("Password:"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(reply) != "pass" {
+		t.Fatalf("got %q, want password", reply)
+	}
+
+	if reply, err := a.Next(nil, false); err != nil || reply != nil {
+		t.Fatalf("final Next = (%q, %v), want (nil, nil)", reply, err)
+	}
+}
+
+func TestLoginAuthUnexpectedPrompt(t *testing.T) {
+	a := &loginAuth{username: "user", password: "pass"}
+	if _, err := a.Next([]byte("Favorite color:"), true); err == nil {
+		t.Fatal("expected an error for an unrecognized server prompt")
+	}
+}
+
+// smtpTransaction records one MAIL/RCPT/DATA cycle as handleFakeSMTPConn saw it,
+// so tests can assert on exactly what went over the wire.
+type smtpTransaction struct {
+	mailFrom string
+	rcptTo   []string
+	data     []byte
+}
+
+// fakeSMTPResult collects the transactions handleFakeSMTPConn processes.
+// Connections run in their own goroutine, so access is mutex-guarded.
+type fakeSMTPResult struct {
+	mu           sync.Mutex
+	transactions []smtpTransaction
+	connections  int
+}
+
+func (r *fakeSMTPResult) record(tx smtpTransaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.transactions = append(r.transactions, tx)
+}
+
+func (r *fakeSMTPResult) connectionCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connections
+}
+
+func (r *fakeSMTPResult) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.transactions)
+}
+
+func (r *fakeSMTPResult) last() smtpTransaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.transactions[len(r.transactions)-1]
+}
+
+// fakeSMTPServer speaks just enough SMTP for smtp.NewClient/EHLO to
+// succeed, advertise the given AUTH mechanisms, and carry a full
+// MAIL/RCPT/DATA transaction end to end. It accepts connections in a loop,
+// so a test can exercise a reconnect.
+func fakeSMTPServer(t *testing.T, mechanisms string) (string, *fakeSMTPResult) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	result := &fakeSMTPResult{}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handleFakeSMTPConn(conn, mechanisms, result)
+		}
+	}()
+
+	return ln.Addr().String(), result
+}
+
+// handleFakeSMTPConn drives one connection through EHLO/MAIL/RCPT/DATA/
+// RSET/QUIT, recording each completed transaction into result. It sends
+// the initial 220 greeting itself; handleStartTLSConn, which hands off an
+// already-greeted, just-upgraded connection, skips that step instead.
+func handleFakeSMTPConn(conn net.Conn, mechanisms string, result *fakeSMTPResult) {
+	handleFakeSMTPConnGreeted(conn, mechanisms, result, true)
+}
+
+func handleFakeSMTPConnGreeted(conn net.Conn, mechanisms string, result *fakeSMTPResult, greet bool) {
+	defer conn.Close()
+
+	result.mu.Lock()
+	result.connections++
+	result.mu.Unlock()
+
+	w := bufio.NewWriter(conn)
+	r := bufio.NewReader(conn)
+
+	writeLine := func(s string) {
+		w.WriteString(s + "\r\n")
+		w.Flush()
+	}
+
+	if greet {
+		writeLine("220 fake.example.com ESMTP")
+	}
+
+	var tx smtpTransaction
+	var dataBuf bytes.Buffer
+	inData := false
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if inData {
+			if line == "." {
+				inData = false
+				tx.data = dataBuf.Bytes()
+				result.record(tx)
+				tx = smtpTransaction{}
+				dataBuf.Reset()
+				writeLine("250 OK")
+				continue
+			}
+			dataBuf.WriteString(line)
+			dataBuf.WriteString("\r\n")
+			continue
+		}
+
+		upper := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(upper, "EHLO"):
+			writeLine("250-fake.example.com")
+			if mechanisms != "" {
+				writeLine("250 AUTH " + mechanisms)
+			} else {
+				writeLine("250 OK")
+			}
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			tx.mailFrom = strings.TrimSpace(line[len("MAIL FROM:"):])
+			writeLine("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			addr := strings.TrimSpace(line[len("RCPT TO:"):])
+			if strings.Contains(addr, "reject@") {
+				writeLine("550 mailbox unavailable")
+				continue
+			}
+			tx.rcptTo = append(tx.rcptTo, addr)
+			writeLine("250 OK")
+		case upper == "DATA":
+			inData = true
+			writeLine("354 go ahead")
+		case strings.HasPrefix(upper, "RSET"):
+			tx = smtpTransaction{}
+			writeLine("250 OK")
+		case strings.HasPrefix(upper, "QUIT"):
+			writeLine("221 bye")
+			return
+		default:
+			writeLine("250 OK")
+		}
+	}
+}
+
+func TestOptionsAuthPrefersCRAMMD5(t *testing.T) {
+	addr, _ := fakeSMTPServer(t, "PLAIN LOGIN CRAM-MD5")
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client, err := smtp.NewClient(conn, "fake.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	opt := &Options{Host: "fake.example.com", Username: "user", Password: "pass"}
+	auth, err := opt.auth(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := auth.(smtp.Auth); !ok {
+		t.Fatal("expected an smtp.Auth")
+	}
+	// smtp.CRAMMD5Auth returns an unexported type; detect it by its Start
+	// proto name instead of the concrete type.
+	proto, _, err := auth.Start(&smtp.ServerInfo{Name: "fake.example.com", TLS: true, Auth: []string{"CRAM-MD5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proto != "CRAM-MD5" {
+		t.Fatalf("proto = %q, want CRAM-MD5", proto)
+	}
+}
+
+func TestOptionsAuthFallsBackToLogin(t *testing.T) {
+	addr, _ := fakeSMTPServer(t, "LOGIN")
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	client, err := smtp.NewClient(conn, "fake.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	opt := &Options{Host: "fake.example.com", Username: "user", Password: "pass"}
+	auth, err := opt.auth(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := auth.(*loginAuth); !ok {
+		t.Fatalf("got %T, want *loginAuth", auth)
+	}
+}
+
+// generateSelfSignedCert returns a throwaway self-signed certificate for
+// host, good for an hour, for use by the TLS fake servers below.
+func generateSelfSignedCert(t *testing.T, host string) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{host},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// TestDialSMTPImplicitTLS covers dialSMTP's TLSImplicit path: the TCP
+// connection itself must already be a TLS handshake, with no plaintext
+// SMTP exchanged first.
+func TestDialSMTPImplicitTLS(t *testing.T) {
+	cert := generateSelfSignedCert(t, "127.0.0.1")
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	result := &fakeSMTPResult{}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		handleFakeSMTPConn(conn, "", result)
+	}()
+
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := Options{
+		Host:      host,
+		Port:      port,
+		TLS:       TLSImplicit,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	client, err := dialSMTP(opt)
+	if err != nil {
+		t.Fatalf("dialSMTP: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Mail("sender@example.com"); err != nil {
+		t.Fatalf("MAIL over implicit TLS: %v", err)
+	}
+	if err := client.Rcpt("rcpt@example.org"); err != nil {
+		t.Fatalf("RCPT over implicit TLS: %v", err)
+	}
+}
+
+// TestDialSMTPStartTLS covers dialSMTP's TLSStartTLS path: it must dial
+// plaintext, upgrade with STARTTLS, then carry the rest of the session
+// (including a fresh EHLO) over the encrypted connection.
+func TestDialSMTPStartTLS(t *testing.T) {
+	cert := generateSelfSignedCert(t, "127.0.0.1")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	result := &fakeSMTPResult{}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		handleStartTLSConn(conn, cert, result)
+	}()
+
+	host, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := Options{
+		Host:      host,
+		Port:      port,
+		TLS:       TLSStartTLS,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	client, err := dialSMTP(opt)
+	if err != nil {
+		t.Fatalf("dialSMTP: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Mail("sender@example.com"); err != nil {
+		t.Fatalf("MAIL after STARTTLS: %v", err)
+	}
+}
+
+// handleStartTLSConn speaks plaintext SMTP up through STARTTLS, then hands
+// the upgraded connection to handleFakeSMTPConnGreeted, without a second
+// greeting, for the rest of the session (including the re-issued EHLO smtp.Client sends after upgrading).
+func handleStartTLSConn(conn net.Conn, cert tls.Certificate, result *fakeSMTPResult) {
+	w := bufio.NewWriter(conn)
+	r := bufio.NewReader(conn)
+
+	writeLine := func(s string) {
+		w.WriteString(s + "\r\n")
+		w.Flush()
+	}
+
+	writeLine("220 fake.example.com ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return
+		}
+		upper := strings.ToUpper(strings.TrimRight(line, "\r\n"))
+
+		switch {
+		case strings.HasPrefix(upper, "EHLO"):
+			writeLine("250-fake.example.com")
+			writeLine("250 STARTTLS")
+		case upper == "STARTTLS":
+			writeLine("220 go ahead")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return
+			}
+			handleFakeSMTPConnGreeted(tlsConn, "", result, false)
+			return
+		default:
+			writeLine("250 OK")
+		}
+	}
+}